@@ -0,0 +1,22 @@
+// Package echo adapts bperm.Permissions to an echo.MiddlewareFunc, so that
+// only callers using Echo pull in the Echo dependency.
+package echo
+
+import (
+	"github.com/labstack/echo/v4"
+	"github.com/wind85/bperm"
+)
+
+// Middleware returns an echo.MiddlewareFunc that denies the request via
+// perm's configured deny function when perm.Rejected returns true.
+func Middleware(perm *bperm.Permissions) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if perm.Rejected(c.Response(), c.Request()) {
+				perm.GetDenyFunc()(c.Response(), c.Request())
+				return nil
+			}
+			return next(c)
+		}
+	}
+}
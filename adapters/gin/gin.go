@@ -0,0 +1,21 @@
+// Package gin adapts bperm.Permissions to a gin.HandlerFunc, so that only
+// callers using Gin pull in the Gin dependency.
+package gin
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/wind85/bperm"
+)
+
+// Middleware returns a gin.HandlerFunc that denies the request via perm's
+// configured deny function when perm.Rejected returns true.
+func Middleware(perm *bperm.Permissions) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if perm.Rejected(c.Writer, c.Request) {
+			perm.GetDenyFunc()(c.Writer, c.Request)
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
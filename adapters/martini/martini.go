@@ -0,0 +1,22 @@
+// Package martini adapts bperm.Permissions to a Martini handler, so that
+// only callers using Martini pull in the Martini dependency.
+package martini
+
+import (
+	"net/http"
+
+	"github.com/go-martini/martini"
+	"github.com/wind85/bperm"
+)
+
+// Handler returns a Martini handler that denies the request via perm's
+// configured deny function when perm.Rejected returns true.
+func Handler(perm *bperm.Permissions) martini.Handler {
+	return func(w http.ResponseWriter, req *http.Request, c martini.Context) {
+		if perm.Rejected(w, req) {
+			perm.GetDenyFunc()(w, req)
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,24 @@
+// Package nethttp adapts bperm.Permissions to net/http by wrapping an
+// http.Handler, so that neither the base bperm package nor callers that
+// don't need it have to depend on any particular router. Handler takes
+// perm and next together, so it does not itself have the
+// func(http.Handler) http.Handler signature that Chi's Use takes.
+package nethttp
+
+import (
+	"net/http"
+
+	"github.com/wind85/bperm"
+)
+
+// Handler wraps next with perm, denying the request via perm's configured
+// deny function when perm.Rejected returns true.
+func Handler(perm *bperm.Permissions, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if perm.Rejected(w, req) {
+			perm.GetDenyFunc()(w, req)
+			return
+		}
+		next.ServeHTTP(w, req)
+	})
+}
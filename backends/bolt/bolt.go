@@ -0,0 +1,255 @@
+// Package bolt provides a BoltDB-backed implementation of bperm.UserState,
+// for applications that want user and permission data stored in a single
+// local file instead of a separate database server.
+package bolt
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strings"
+
+	bolt "go.etcd.io/bbolt"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/wind85/bperm"
+	"github.com/wind85/bperm/backends/session"
+)
+
+var (
+	usersBucket         = []byte("users")
+	usernamesBucket     = []byte("usernames")
+	loggedInBucket      = []byte("loggedin")
+	adminsBucket        = []byte("admins")
+	groupsBucket        = []byte("groups")
+	confirmationsBucket = []byte("confirmations")
+)
+
+// UserState keeps track of users, login state and permissions, storing
+// everything in a BoltDB file.
+type UserState struct {
+	db *bolt.DB
+}
+
+// New creates a new Bolt-backed UserState, using the Bolt database at the
+// given path. The file is created if it does not already exist.
+func New(path string) (*UserState, error) {
+	db, err := bolt.Open(path, 0600, nil)
+	if err != nil {
+		return nil, err
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{usersBucket, usernamesBucket, loggedInBucket, adminsBucket, groupsBucket, confirmationsBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &UserState{db: db}, nil
+}
+
+// NewPermissions initializes a bperm.Permissions struct with a Bolt-backed
+// UserState, using the Bolt database at the given path.
+func NewPermissions(path string) (*bperm.Permissions, error) {
+	state, err := New(path)
+	if err != nil {
+		return nil, err
+	}
+	return bperm.NewFromUserState(state), nil
+}
+
+// Username returns the username of the current user, based on the
+// session cookie of the request. Returns an empty string if not logged in.
+func (state *UserState) Username(req *http.Request) string {
+	return session.Username(req)
+}
+
+// Users returns every known username.
+func (state *UserState) Users() []string {
+	var all []string
+	state.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(usernamesBucket).ForEach(func(k, v []byte) error {
+			all = append(all, string(k))
+			return nil
+		})
+	})
+	return all
+}
+
+// HasUser checks if the given username exists.
+func (state *UserState) HasUser(username string) bool {
+	has := false
+	state.db.View(func(tx *bolt.Tx) error {
+		has = tx.Bucket(usernamesBucket).Get([]byte(username)) != nil
+		return nil
+	})
+	return has
+}
+
+// Login marks the given username as logged in and sets a session cookie.
+func (state *UserState) Login(w http.ResponseWriter, username string) {
+	state.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(loggedInBucket).Put([]byte(username), []byte("true"))
+	})
+	session.Set(w, username)
+}
+
+// Logout marks the given username as logged out.
+func (state *UserState) Logout(username string) {
+	state.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(loggedInBucket).Delete([]byte(username))
+	})
+}
+
+// IsLoggedIn checks if the given username is logged in.
+func (state *UserState) IsLoggedIn(username string) bool {
+	in := false
+	state.db.View(func(tx *bolt.Tx) error {
+		in = tx.Bucket(loggedInBucket).Get([]byte(username)) != nil
+		return nil
+	})
+	return in
+}
+
+// IsConfirmed checks if the given username has confirmed their account.
+func (state *UserState) IsConfirmed(username string) bool {
+	confirmed := false
+	state.db.View(func(tx *bolt.Tx) error {
+		confirmed = string(tx.Bucket(usersBucket).Get([]byte(username+":confirmed"))) == "true"
+		return nil
+	})
+	return confirmed
+}
+
+// AddUser adds a new user with the given username, password and email. The
+// password is hashed with bcrypt before being stored.
+func (state *UserState) AddUser(username, password, email string) error {
+	hashed, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	return state.db.Update(func(tx *bolt.Tx) error {
+		if err := tx.Bucket(usernamesBucket).Put([]byte(username), []byte("true")); err != nil {
+			return err
+		}
+		if err := tx.Bucket(usersBucket).Put([]byte(username+":password"), hashed); err != nil {
+			return err
+		}
+		return tx.Bucket(usersBucket).Put([]byte(username+":email"), []byte(email))
+	})
+}
+
+// RemoveUser removes the given username.
+func (state *UserState) RemoveUser(username string) {
+	state.db.Update(func(tx *bolt.Tx) error {
+		tx.Bucket(usernamesBucket).Delete([]byte(username))
+		tx.Bucket(adminsBucket).Delete([]byte(username))
+		tx.Bucket(loggedInBucket).Delete([]byte(username))
+		return nil
+	})
+}
+
+// SetAdminStatus marks the given username as an administrator.
+func (state *UserState) SetAdminStatus(username string) {
+	state.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(adminsBucket).Put([]byte(username), []byte("true"))
+	})
+}
+
+// RemoveAdminStatus removes administrator rights from the given username.
+func (state *UserState) RemoveAdminStatus(username string) {
+	state.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(adminsBucket).Delete([]byte(username))
+	})
+}
+
+// IsCurrentUserAdmin checks if the current user, as identified by the
+// request's session cookie, is an administrator.
+func (state *UserState) IsCurrentUserAdmin(req *http.Request) (bool, error) {
+	username := state.Username(req)
+	if username == "" {
+		return false, nil
+	}
+	isAdmin := false
+	err := state.db.View(func(tx *bolt.Tx) error {
+		isAdmin = tx.Bucket(adminsBucket).Get([]byte(username)) != nil
+		return nil
+	})
+	return isAdmin, err
+}
+
+// AddGroup creates a new named group, if it does not already exist.
+func (state *UserState) AddGroup(name string) error {
+	return state.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(groupsBucket).Put([]byte(name), []byte("true"))
+	})
+}
+
+// AssignUserToGroup adds the given username to the named group. The group
+// is created first if it does not already exist.
+func (state *UserState) AssignUserToGroup(username, group string) error {
+	if err := state.AddGroup(group); err != nil {
+		return err
+	}
+	groups := state.UserGroups(username)
+	for _, g := range groups {
+		if g == group {
+			return nil
+		}
+	}
+	groups = append(groups, group)
+	return state.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(usersBucket).Put([]byte(username+":groups"), []byte(strings.Join(groups, ",")))
+	})
+}
+
+// UserGroups returns every group the given username belongs to.
+func (state *UserState) UserGroups(username string) []string {
+	var joined string
+	state.db.View(func(tx *bolt.Tx) error {
+		joined = string(tx.Bucket(usersBucket).Get([]byte(username + ":groups")))
+		return nil
+	})
+	if joined == "" {
+		return nil
+	}
+	return strings.Split(joined, ",")
+}
+
+// GenerateConfirmationCode generates and stores a new confirmation code
+// for the given username, to be handed to ConfirmUser later.
+func (state *UserState) GenerateConfirmationCode(username string) (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	code := hex.EncodeToString(buf)
+	err := state.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(confirmationsBucket).Put([]byte(code), []byte(username))
+	})
+	if err != nil {
+		return "", err
+	}
+	return code, nil
+}
+
+// ConfirmUser marks the user owning the given confirmation code as
+// confirmed. It returns an error if the code is unknown.
+func (state *UserState) ConfirmUser(code string) error {
+	return state.db.Update(func(tx *bolt.Tx) error {
+		confirmations := tx.Bucket(confirmationsBucket)
+		username := string(confirmations.Get([]byte(code)))
+		if username == "" {
+			return errors.New("bperm/backends/bolt: invalid confirmation code")
+		}
+		if err := tx.Bucket(usersBucket).Put([]byte(username+":confirmed"), []byte("true")); err != nil {
+			return err
+		}
+		return confirmations.Delete([]byte(code))
+	})
+}
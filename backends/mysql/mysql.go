@@ -0,0 +1,244 @@
+// Package mysql provides a MySQL-backed implementation of bperm.UserState.
+package mysql
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"net/http"
+
+	_ "github.com/go-sql-driver/mysql"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/wind85/bperm"
+	"github.com/wind85/bperm/backends/session"
+)
+
+// UserState keeps track of users, login state and permissions, storing
+// everything in a MySQL database.
+type UserState struct {
+	db *sql.DB
+}
+
+const usersSchema = `
+CREATE TABLE IF NOT EXISTS users (
+	username VARCHAR(255) NOT NULL,
+	password TEXT NOT NULL,
+	email TEXT NOT NULL,
+	confirmed BOOLEAN NOT NULL DEFAULT FALSE,
+	admin BOOLEAN NOT NULL DEFAULT FALSE,
+	logged_in BOOLEAN NOT NULL DEFAULT FALSE,
+	PRIMARY KEY (username)
+)`
+
+const userGroupsSchema = `
+CREATE TABLE IF NOT EXISTS user_groups (
+	username VARCHAR(255) NOT NULL,
+	group_name VARCHAR(255) NOT NULL,
+	PRIMARY KEY (username, group_name)
+)`
+
+const confirmationsSchema = `
+CREATE TABLE IF NOT EXISTS confirmations (
+	code VARCHAR(32) NOT NULL,
+	username VARCHAR(255) NOT NULL,
+	PRIMARY KEY (code)
+)`
+
+// New creates a new MySQL-backed UserState, connecting using the given
+// data source name.
+func New(dsn string) (*UserState, error) {
+	db, err := sql.Open("mysql", dsn)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.Ping(); err != nil {
+		return nil, err
+	}
+	if _, err := db.Exec(usersSchema); err != nil {
+		return nil, err
+	}
+	if _, err := db.Exec(userGroupsSchema); err != nil {
+		return nil, err
+	}
+	if _, err := db.Exec(confirmationsSchema); err != nil {
+		return nil, err
+	}
+	return &UserState{db: db}, nil
+}
+
+// NewPermissions initializes a bperm.Permissions struct with a MySQL-backed
+// UserState, connecting using the given data source name.
+func NewPermissions(dsn string) (*bperm.Permissions, error) {
+	state, err := New(dsn)
+	if err != nil {
+		return nil, err
+	}
+	return bperm.NewFromUserState(state), nil
+}
+
+// Username returns the username of the current user, based on the
+// session cookie of the request. Returns an empty string if not logged in.
+func (state *UserState) Username(req *http.Request) string {
+	return session.Username(req)
+}
+
+// Users returns every known username.
+func (state *UserState) Users() []string {
+	rows, err := state.db.Query("SELECT username FROM users")
+	if err != nil {
+		return nil
+	}
+	defer rows.Close()
+	var all []string
+	for rows.Next() {
+		var username string
+		if rows.Scan(&username) == nil {
+			all = append(all, username)
+		}
+	}
+	return all
+}
+
+// HasUser checks if the given username exists.
+func (state *UserState) HasUser(username string) bool {
+	var exists bool
+	state.db.QueryRow("SELECT EXISTS(SELECT 1 FROM users WHERE username = ?)", username).Scan(&exists)
+	return exists
+}
+
+// Login marks the given username as logged in and sets a session cookie.
+func (state *UserState) Login(w http.ResponseWriter, username string) {
+	state.db.Exec("UPDATE users SET logged_in = TRUE WHERE username = ?", username)
+	session.Set(w, username)
+}
+
+// Logout marks the given username as logged out.
+func (state *UserState) Logout(username string) {
+	state.db.Exec("UPDATE users SET logged_in = FALSE WHERE username = ?", username)
+}
+
+// IsLoggedIn checks if the given username is logged in.
+func (state *UserState) IsLoggedIn(username string) bool {
+	var loggedIn bool
+	state.db.QueryRow("SELECT logged_in FROM users WHERE username = ?", username).Scan(&loggedIn)
+	return loggedIn
+}
+
+// IsConfirmed checks if the given username has confirmed their account.
+func (state *UserState) IsConfirmed(username string) bool {
+	var confirmed bool
+	state.db.QueryRow("SELECT confirmed FROM users WHERE username = ?", username).Scan(&confirmed)
+	return confirmed
+}
+
+// AddUser adds a new user with the given username, password and email. The
+// password is hashed with bcrypt before being stored.
+func (state *UserState) AddUser(username, password, email string) error {
+	hashed, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	_, err = state.db.Exec(
+		"INSERT INTO users (username, password, email) VALUES (?, ?, ?)",
+		username, string(hashed), email)
+	return err
+}
+
+// RemoveUser removes the given username.
+func (state *UserState) RemoveUser(username string) {
+	state.db.Exec("DELETE FROM users WHERE username = ?", username)
+}
+
+// SetAdminStatus marks the given username as an administrator.
+func (state *UserState) SetAdminStatus(username string) {
+	state.db.Exec("UPDATE users SET admin = TRUE WHERE username = ?", username)
+}
+
+// RemoveAdminStatus removes administrator rights from the given username.
+func (state *UserState) RemoveAdminStatus(username string) {
+	state.db.Exec("UPDATE users SET admin = FALSE WHERE username = ?", username)
+}
+
+// IsCurrentUserAdmin checks if the current user, as identified by the
+// request's session cookie, is an administrator.
+func (state *UserState) IsCurrentUserAdmin(req *http.Request) (bool, error) {
+	username := state.Username(req)
+	if username == "" {
+		return false, nil
+	}
+	var isAdmin bool
+	err := state.db.QueryRow("SELECT admin FROM users WHERE username = ?", username).Scan(&isAdmin)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return isAdmin, err
+}
+
+// AddGroup creates a new named group, if it does not already exist.
+func (state *UserState) AddGroup(name string) error {
+	_, err := state.db.Exec(
+		"INSERT IGNORE INTO user_groups (username, group_name) VALUES ('', ?)", name)
+	return err
+}
+
+// AssignUserToGroup adds the given username to the named group. The group
+// is created first if it does not already exist.
+func (state *UserState) AssignUserToGroup(username, group string) error {
+	if err := state.AddGroup(group); err != nil {
+		return err
+	}
+	_, err := state.db.Exec(
+		"INSERT IGNORE INTO user_groups (username, group_name) VALUES (?, ?)", username, group)
+	return err
+}
+
+// UserGroups returns every group the given username belongs to.
+func (state *UserState) UserGroups(username string) []string {
+	rows, err := state.db.Query(
+		"SELECT group_name FROM user_groups WHERE username = ?", username)
+	if err != nil {
+		return nil
+	}
+	defer rows.Close()
+	var groups []string
+	for rows.Next() {
+		var group string
+		if rows.Scan(&group) == nil {
+			groups = append(groups, group)
+		}
+	}
+	return groups
+}
+
+// GenerateConfirmationCode generates and stores a new confirmation code
+// for the given username, to be handed to ConfirmUser later.
+func (state *UserState) GenerateConfirmationCode(username string) (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	code := hex.EncodeToString(buf)
+	_, err := state.db.Exec(
+		"INSERT INTO confirmations (code, username) VALUES (?, ?)", code, username)
+	if err != nil {
+		return "", err
+	}
+	return code, nil
+}
+
+// ConfirmUser marks the user owning the given confirmation code as
+// confirmed. It returns an error if the code is unknown.
+func (state *UserState) ConfirmUser(code string) error {
+	var username string
+	err := state.db.QueryRow(
+		"SELECT username FROM confirmations WHERE code = ?", code).Scan(&username)
+	if err != nil {
+		return err
+	}
+	if _, err := state.db.Exec("UPDATE users SET confirmed = TRUE WHERE username = ?", username); err != nil {
+		return err
+	}
+	_, err = state.db.Exec("DELETE FROM confirmations WHERE code = ?", code)
+	return err
+}
@@ -0,0 +1,194 @@
+// Package redis provides a Redis-backed implementation of bperm.UserState.
+package redis
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strings"
+
+	simpleredis "github.com/xyproto/simpleredis"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/wind85/bperm/backends/session"
+)
+
+// UserState keeps track of users, login state and permissions, storing
+// everything in Redis.
+type UserState struct {
+	users         *simpleredis.HashMap
+	usernames     *simpleredis.Set
+	loggedIn      *simpleredis.Set
+	admins        *simpleredis.Set
+	groups        *simpleredis.Set
+	confirmations *simpleredis.HashMap
+	pool          *simpleredis.ConnectionPool
+}
+
+// New creates a new Redis-backed UserState, connecting to the Redis server
+// at addr ("host:port"). An empty addr means localhost with the default
+// Redis port.
+func New(addr string) (*UserState, error) {
+	var pool *simpleredis.ConnectionPool
+	if addr == "" {
+		pool = simpleredis.NewConnectionPool()
+	} else {
+		pool = simpleredis.NewConnectionPoolHost(addr)
+	}
+	state := &UserState{
+		users:         simpleredis.NewHashMap(pool, "users"),
+		usernames:     simpleredis.NewSet(pool, "usernames"),
+		loggedIn:      simpleredis.NewSet(pool, "loggedin"),
+		admins:        simpleredis.NewSet(pool, "admins"),
+		groups:        simpleredis.NewSet(pool, "groups"),
+		confirmations: simpleredis.NewHashMap(pool, "confirmations"),
+		pool:          pool,
+	}
+	return state, nil
+}
+
+// Username returns the username of the current user, based on the
+// session cookie of the request. Returns an empty string if not logged in.
+func (state *UserState) Username(req *http.Request) string {
+	return session.Username(req)
+}
+
+// Users returns every known username.
+func (state *UserState) Users() []string {
+	all, _ := state.usernames.GetAll()
+	return all
+}
+
+// HasUser checks if the given username exists.
+func (state *UserState) HasUser(username string) bool {
+	has, _ := state.usernames.Has(username)
+	return has
+}
+
+// Login marks the given username as logged in and sets a session cookie.
+func (state *UserState) Login(w http.ResponseWriter, username string) {
+	state.loggedIn.Add(username)
+	session.Set(w, username)
+}
+
+// Logout marks the given username as logged out.
+func (state *UserState) Logout(username string) {
+	state.loggedIn.Del(username)
+}
+
+// IsLoggedIn checks if the given username is logged in.
+func (state *UserState) IsLoggedIn(username string) bool {
+	in, _ := state.loggedIn.Has(username)
+	return in
+}
+
+// IsConfirmed checks if the given username has confirmed their account.
+func (state *UserState) IsConfirmed(username string) bool {
+	confirmed, _ := state.users.Get(username, "confirmed")
+	return confirmed == "true"
+}
+
+// AddUser adds a new user with the given username, password and email. The
+// password is hashed with bcrypt before being stored.
+func (state *UserState) AddUser(username, password, email string) error {
+	hashed, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	state.usernames.Add(username)
+	state.users.Set(username, "password", string(hashed))
+	state.users.Set(username, "email", email)
+	return nil
+}
+
+// RemoveUser removes the given username.
+func (state *UserState) RemoveUser(username string) {
+	state.usernames.Del(username)
+	state.admins.Del(username)
+	state.loggedIn.Del(username)
+}
+
+// SetAdminStatus marks the given username as an administrator.
+func (state *UserState) SetAdminStatus(username string) {
+	state.admins.Add(username)
+}
+
+// RemoveAdminStatus removes administrator rights from the given username.
+func (state *UserState) RemoveAdminStatus(username string) {
+	state.admins.Del(username)
+}
+
+// IsCurrentUserAdmin checks if the current user, as identified by the
+// request's session cookie, is an administrator.
+func (state *UserState) IsCurrentUserAdmin(req *http.Request) (bool, error) {
+	username := state.Username(req)
+	if username == "" {
+		return false, nil
+	}
+	isAdmin, err := state.admins.Has(username)
+	if err != nil {
+		return false, err
+	}
+	return isAdmin, nil
+}
+
+// AddGroup creates a new named group, if it does not already exist.
+func (state *UserState) AddGroup(name string) error {
+	state.groups.Add(name)
+	return nil
+}
+
+// AssignUserToGroup adds the given username to the named group. The group
+// is created first if it does not already exist.
+func (state *UserState) AssignUserToGroup(username, group string) error {
+	if has, _ := state.groups.Has(group); !has {
+		if err := state.AddGroup(group); err != nil {
+			return err
+		}
+	}
+	groups := state.UserGroups(username)
+	for _, g := range groups {
+		if g == group {
+			return nil
+		}
+	}
+	return state.users.Set(username, "groups", strings.Join(append(groups, group), ","))
+}
+
+// UserGroups returns every group the given username belongs to.
+func (state *UserState) UserGroups(username string) []string {
+	joined, err := state.users.Get(username, "groups")
+	if err != nil || joined == "" {
+		return nil
+	}
+	return strings.Split(joined, ",")
+}
+
+// GenerateConfirmationCode generates and stores a new confirmation code
+// for the given username, to be handed to ConfirmUser later.
+func (state *UserState) GenerateConfirmationCode(username string) (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	code := hex.EncodeToString(buf)
+	if err := state.confirmations.Set(code, "username", username); err != nil {
+		return "", err
+	}
+	return code, nil
+}
+
+// ConfirmUser marks the user owning the given confirmation code as
+// confirmed. It returns an error if the code is unknown.
+func (state *UserState) ConfirmUser(code string) error {
+	username, err := state.confirmations.Get(code, "username")
+	if err != nil || username == "" {
+		return errors.New("bperm/backends/redis: invalid confirmation code")
+	}
+	if err := state.users.Set(username, "confirmed", "true"); err != nil {
+		return err
+	}
+	state.confirmations.Del(code)
+	return nil
+}
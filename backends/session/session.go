@@ -0,0 +1,73 @@
+// Package session implements a signed session cookie shared by the
+// backends under bperm/backends, so that a client cannot impersonate
+// another user by simply sending a cookie with their username in it.
+package session
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+)
+
+// CookieName is the name of the session cookie set by Set.
+const CookieName = "user"
+
+// key signs session cookies for the lifetime of the running process. It is
+// generated once at startup rather than persisted, so a session does not
+// survive a restart. It is unexported and only set by newKey, so it cannot
+// be seeded from a stored value outside this package.
+var key = newKey()
+
+func newKey() []byte {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		panic("bperm/backends/session: failed to generate signing key: " + err.Error())
+	}
+	return buf
+}
+
+func sign(username string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(username))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Set signs and stores username in a session cookie on w.
+func Set(w http.ResponseWriter, username string) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     CookieName,
+		Value:    username + "|" + sign(username),
+		Path:     "/",
+		HttpOnly: true,
+	})
+}
+
+// Username returns the username carried by req's session cookie, after
+// verifying its signature. Returns an empty string if there is no session
+// cookie, or if its signature doesn't match.
+func Username(req *http.Request) string {
+	cookie, err := req.Cookie(CookieName)
+	if err != nil {
+		return ""
+	}
+	username, sig, ok := split(cookie.Value)
+	if !ok {
+		return ""
+	}
+	if !hmac.Equal([]byte(sig), []byte(sign(username))) {
+		return ""
+	}
+	return username
+}
+
+// split separates a cookie value of the form "username|signature".
+func split(value string) (username, sig string, ok bool) {
+	i := strings.LastIndex(value, "|")
+	if i < 0 {
+		return "", "", false
+	}
+	return value[:i], value[i+1:], true
+}
@@ -0,0 +1,55 @@
+package session
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func requestWithCookie(cookie *http.Cookie) *http.Request {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.AddCookie(cookie)
+	return req
+}
+
+func TestSetAndUsername_RoundTrips(t *testing.T) {
+	w := httptest.NewRecorder()
+	Set(w, "alice")
+
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 {
+		t.Fatalf("expected exactly one cookie, got %d", len(cookies))
+	}
+
+	if got := Username(requestWithCookie(cookies[0])); got != "alice" {
+		t.Fatalf("Username() = %q, want %q", got, "alice")
+	}
+}
+
+func TestUsername_RejectsForgedCookie(t *testing.T) {
+	forged := &http.Cookie{Name: CookieName, Value: "admin|not-a-real-signature"}
+	if got := Username(requestWithCookie(forged)); got != "" {
+		t.Fatalf("a cookie with an unsigned username should not be trusted, got %q", got)
+	}
+}
+
+func TestUsername_RejectsSignatureForAnotherUsername(t *testing.T) {
+	w := httptest.NewRecorder()
+	Set(w, "alice")
+	aliceCookie := w.Result().Cookies()[0]
+
+	// Swap in a different username but keep alice's signature.
+	_, sig, _ := split(aliceCookie.Value)
+	forged := &http.Cookie{Name: CookieName, Value: "admin|" + sig}
+
+	if got := Username(requestWithCookie(forged)); got != "" {
+		t.Fatalf("a signature for a different username should not validate, got %q", got)
+	}
+}
+
+func TestUsername_NoCookie(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	if got := Username(req); got != "" {
+		t.Fatalf("Username() with no cookie = %q, want empty", got)
+	}
+}
@@ -5,6 +5,8 @@ package bperm
 import (
 	"net/http"
 	"strings"
+
+	"github.com/wind85/bperm/backends/redis"
 )
 
 // Paths is the Url path type
@@ -18,38 +20,54 @@ const (
 
 // The Permissions structure keeps track of the permissions for various path prefixes
 type Permissions struct {
-	state        *UserState
-	paths        map[Paths][]string
-	rootIsPublic bool
-	denied       http.HandlerFunc
+	state               UserState
+	paths               map[Paths][]string
+	groupPaths          map[string][]string
+	rules               []compiledRule
+	rootIsPublic        bool
+	denied              http.HandlerFunc
+	mailer              Mailer
+	requireConfirmation bool
 }
 
 const (
 	Version = 2.0 // Version number. Stable API within major version numbers.
 )
 
-// New initializes a Permissions struct with all the default settings.
+// New initializes a Permissions struct with all the default settings,
+// backed by a local Redis server.
 func New() (*Permissions, error) {
-	state, err := NewUserStateSimple()
-	if err != nil {
-		return nil, err
-	}
-	return NewFromUserState(state), nil
+	return NewFromRedis("")
+}
+
+// NewWithConf initializes a Permissions struct backed by Redis, using addr
+// as the "host:port" to connect to. An empty addr means localhost with the
+// default Redis port.
+func NewWithConf(addr string) (*Permissions, error) {
+	return NewFromRedis(addr)
 }
 
-// NewWithConf initializes a Permissions struct with a database filename
-func NewWithConf(name string) (*Permissions, error) {
-	state, err := NewUserState(name, true)
+// NewFromRedis initializes a Permissions struct with a Redis-backed
+// UserState, connecting to addr ("host:port"). An empty addr means
+// localhost with the default Redis port.
+//
+// Redis is the only backend with a constructor here: it's the documented
+// default backend, used by New and NewWithConf. The Bolt, MySQL and
+// Postgres backends are equally supported, but their constructors live in
+// their own backend packages (bolt.NewPermissions, mysql.NewPermissions,
+// postgres.NewPermissions) so that importing bperm doesn't pull in their
+// driver dependencies for callers who don't use them.
+func NewFromRedis(addr string) (*Permissions, error) {
+	state, err := redis.New(addr)
 	if err != nil {
 		return nil, err
 	}
 	return NewFromUserState(state), nil
-
 }
 
 // NewFromUserState initializes a Permissions struct with the given UserState and
 // a few default paths for admin/user/public path prefixes.
-func NewFromUserState(state *UserState) *Permissions {
+func NewFromUserState(state UserState) *Permissions {
 	paths := map[Paths][]string{}
 	paths[aPaths] = []string{"/admin"}
 	paths[uPaths] = []string{"/profiles", "/data"}
@@ -60,10 +78,13 @@ func NewFromUserState(state *UserState) *Permissions {
 		"/robots.txt", "/sitemap_index.xml",
 	}
 
-	return &Permissions{state,
-		paths,
-		true,
-		DefaultDenyFunc}
+	return &Permissions{
+		state:        state,
+		paths:        paths,
+		groupPaths:   map[string][]string{},
+		rootIsPublic: true,
+		denied:       DefaultDenyFunc,
+	}
 }
 
 // SetDenyFunc specifies a http.HandlerFunc for when the permissions are denied
@@ -82,8 +103,8 @@ func DefaultDenyFunc(w http.ResponseWriter, req *http.Request) {
 	http.Error(w, "Permission denied.", http.StatusForbidden)
 }
 
-// GetUserState retrieves the UserState struct
-func (perm *Permissions) GetUserState() *UserState {
+// GetUserState retrieves the UserState backing this Permissions struct
+func (perm *Permissions) GetUserState() UserState {
 	return perm.state
 }
 
@@ -104,6 +125,103 @@ func (perm *Permissions) Reset() {
 	perm.paths[uPaths] = []string{}
 }
 
+// AddGroup creates a new named group, e.g. "editor" or "billing".
+func (perm *Permissions) AddGroup(name string) error {
+	return perm.state.AddGroup(name)
+}
+
+// AssignUserToGroup adds the given username to the named group.
+func (perm *Permissions) AssignUserToGroup(username, group string) error {
+	return perm.state.AssignUserToGroup(username, group)
+}
+
+// AddGroupPath gates the given URL path prefix behind membership of the
+// named group.
+func (perm *Permissions) AddGroupPath(group, prefix string) {
+	perm.groupPaths[group] = append(perm.groupPaths[group], prefix)
+}
+
+// RequireGroup gates every given URL path prefix behind membership of the
+// named group. It is a shortcut for calling AddGroupPath once per prefix.
+func (perm *Permissions) RequireGroup(group string, prefixes ...string) {
+	for _, prefix := range prefixes {
+		perm.AddGroupPath(group, prefix)
+	}
+}
+
+// IsUserInGroup checks if the current user, as identified by the request,
+// belongs to the named group.
+func (perm *Permissions) IsUserInGroup(req *http.Request, group string) bool {
+	username := perm.state.Username(req)
+	if username == "" {
+		return false
+	}
+	for _, g := range perm.state.UserGroups(username) {
+		if g == group {
+			return true
+		}
+	}
+	return false
+}
+
+// SetMailer sets the Mailer used for sending confirmation emails when
+// registering new users.
+func (perm *Permissions) SetMailer(mailer Mailer) {
+	perm.mailer = mailer
+}
+
+// RequireConfirmation sets whether logged in but unconfirmed users should
+// be denied access to user paths. Defaults to false.
+func (perm *Permissions) RequireConfirmation(require bool) {
+	perm.requireConfirmation = require
+}
+
+// GenerateConfirmationCode generates a new confirmation code for the given
+// username, to be handed to ConfirmUser once the user acts on it.
+func (perm *Permissions) GenerateConfirmationCode(username string) (string, error) {
+	return perm.state.GenerateConfirmationCode(username)
+}
+
+// ConfirmUser marks the user owning the given confirmation code as
+// confirmed.
+func (perm *Permissions) ConfirmUser(code string) error {
+	return perm.state.ConfirmUser(code)
+}
+
+// Register adds a new user and, if a Mailer has been configured with
+// SetMailer, emails them a confirmation code.
+func (perm *Permissions) Register(username, password, email string) error {
+	if err := perm.state.AddUser(username, password, email); err != nil {
+		return err
+	}
+	code, err := perm.GenerateConfirmationCode(username)
+	if err != nil {
+		return err
+	}
+	if perm.mailer == nil {
+		return nil
+	}
+	return perm.mailer.SendConfirmation(email, code)
+}
+
+// rejectedByKind checks if a request should be rejected, given the path
+// bucket (admin/user/public) it was matched against by a Rule.
+func (perm *Permissions) rejectedByKind(kind Paths, req *http.Request) bool {
+	switch kind {
+	case aPaths:
+		ok, _ := perm.state.IsCurrentUserAdmin(req)
+		return !ok
+	case uPaths:
+		username := perm.state.Username(req)
+		if username == "" {
+			return true
+		}
+		return perm.requireConfirmation && !perm.state.IsConfirmed(username)
+	default:
+		return false
+	}
+}
+
 // Rejected checks if a given http request should be rejected
 func (perm *Permissions) Rejected(w http.ResponseWriter, req *http.Request) bool {
 	var (
@@ -112,22 +230,69 @@ func (perm *Permissions) Rejected(w http.ResponseWriter, req *http.Request) bool
 	)
 	// If it's not "/" and set to be public regardless of permissions
 	if !(perm.rootIsPublic && path == "/") {
-		// Reject if it is an admin page and user is not an admin
+		// A matching Rule, if any, takes precedence over the legacy
+		// prefix-based paths below.
+		if kind, ok := perm.matchRule(req); ok {
+			return perm.rejectedByKind(kind, req)
+		}
+		// An admin page takes precedence over everything below it: an admin
+		// prefix match is resolved here and now, rather than continuing on
+		// to the user-path/group checks where a non-gating-group admin
+		// could otherwise still be rejected.
 		for _, prefix := range perm.paths[aPaths] {
 			if strings.HasPrefix(path, prefix) {
-				if ok, _ := perm.state.IsCurrentUserAdmin(req); !ok {
-					reject = true
+				ok, _ := perm.state.IsCurrentUserAdmin(req)
+				return !ok
+			}
+		}
+		// A matching user page is handled here and does not fall through to
+		// the public-page check below: it requires a logged in user, and,
+		// when confirmation is required, a confirmed one.
+		matchedUserPath := false
+		if !reject {
+			for _, prefix := range perm.paths[uPaths] {
+				if strings.HasPrefix(path, prefix) {
+					matchedUserPath = true
+					username := perm.state.Username(req)
+					if username == "" {
+						reject = true
+					} else if perm.requireConfirmation && !perm.state.IsConfirmed(username) {
+						reject = true
+					}
 					break
 				}
 			}
 		}
-		if !reject {
-			// Reject if it's a user page and the user doesn't have perm
-			// not needed any longer all users have user rights
-			// TOUGH is the place to put the not confirmed logic
-			// can't view this yet.
+		// Reject if the path is gated behind one or more groups and the
+		// user isn't a member of any of them. Every group gating the path
+		// is collected first, so the outcome doesn't depend on map
+		// iteration order when more than one group gates the same prefix.
+		groupGated := false
+		if !reject && !matchedUserPath {
+			var gatingGroups []string
+			for group, prefixes := range perm.groupPaths {
+				for _, prefix := range prefixes {
+					if strings.HasPrefix(path, prefix) {
+						gatingGroups = append(gatingGroups, group)
+						break
+					}
+				}
+			}
+			if len(gatingGroups) > 0 {
+				groupGated = true
+				allowed := false
+				for _, group := range gatingGroups {
+					if perm.IsUserInGroup(req, group) {
+						allowed = true
+						break
+					}
+				}
+				if !allowed {
+					reject = true
+				}
+			}
 		}
-		if !reject {
+		if !reject && !groupGated && !matchedUserPath {
 			// Reject if it's not a public page
 			found := false
 			for _, prefix := range perm.paths[pPaths] {
@@ -0,0 +1,108 @@
+package bperm
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRejected_UserPathDeniesAnonymous(t *testing.T) {
+	perm := newPerm()
+
+	req := httptest.NewRequest(http.MethodGet, "/profiles", nil)
+	if !perm.Rejected(httptest.NewRecorder(), req) {
+		t.Fatal("an anonymous request to a user path should be rejected")
+	}
+}
+
+func TestRejected_UserPathAllowsAnyLoggedInUserByDefault(t *testing.T) {
+	perm := newPerm()
+
+	req := httptest.NewRequest(http.MethodGet, "/profiles", nil)
+	req.Header.Set("X-Test-User", "alice")
+	if perm.Rejected(httptest.NewRecorder(), req) {
+		t.Fatal("a logged in, unconfirmed user should be allowed when RequireConfirmation is off")
+	}
+}
+
+func TestRejected_UserPathRequiresConfirmationWhenEnabled(t *testing.T) {
+	perm := newPerm()
+	perm.RequireConfirmation(true)
+
+	req := httptest.NewRequest(http.MethodGet, "/profiles", nil)
+	req.Header.Set("X-Test-User", "alice")
+	if !perm.Rejected(httptest.NewRecorder(), req) {
+		t.Fatal("an unconfirmed user should be rejected once RequireConfirmation is on")
+	}
+
+	perm.state.(*mockState).confirmed["alice"] = true
+	if perm.Rejected(httptest.NewRecorder(), req) {
+		t.Fatal("a confirmed user should be allowed")
+	}
+}
+
+func TestRejected_UserPathRequiresConfirmationDeniesAnonymous(t *testing.T) {
+	perm := newPerm()
+	perm.RequireConfirmation(true)
+
+	req := httptest.NewRequest(http.MethodGet, "/data", nil)
+	if !perm.Rejected(httptest.NewRecorder(), req) {
+		t.Fatal("an anonymous request should be rejected regardless of RequireConfirmation")
+	}
+}
+
+func TestRejected_PublicPathAllowsAnonymous(t *testing.T) {
+	perm := newPerm()
+
+	req := httptest.NewRequest(http.MethodGet, "/login", nil)
+	if perm.Rejected(httptest.NewRecorder(), req) {
+		t.Fatal("a public path should be reachable by an anonymous request")
+	}
+}
+
+func TestRejected_UnregisteredPathIsDenied(t *testing.T) {
+	perm := newPerm()
+
+	req := httptest.NewRequest(http.MethodGet, "/something-else", nil)
+	if !perm.Rejected(httptest.NewRecorder(), req) {
+		t.Fatal("a path that is neither admin, user, group-gated nor public should be rejected")
+	}
+}
+
+func TestRejected_GroupGatedPathAllowsAnyMatchingGroupMember(t *testing.T) {
+	perm := newPerm()
+	perm.AddGroupPath("editor", "/x")
+	perm.AddGroupPath("billing", "/x")
+	perm.state.(*mockState).groups["alice"] = []string{"editor"}
+
+	req := httptest.NewRequest(http.MethodGet, "/x", nil)
+	req.Header.Set("X-Test-User", "alice")
+	if perm.Rejected(httptest.NewRecorder(), req) {
+		t.Fatal("a member of only one of two groups gating the same prefix should still be allowed")
+	}
+}
+
+func TestRejected_GroupGatedPathDeniesNonMember(t *testing.T) {
+	perm := newPerm()
+	perm.AddGroupPath("editor", "/x")
+	perm.state.(*mockState).groups["alice"] = []string{"billing"}
+
+	req := httptest.NewRequest(http.MethodGet, "/x", nil)
+	req.Header.Set("X-Test-User", "alice")
+	if !perm.Rejected(httptest.NewRecorder(), req) {
+		t.Fatal("a user in none of the groups gating the prefix should be rejected")
+	}
+}
+
+func TestRejected_AdminOverridesGroupGating(t *testing.T) {
+	perm := newPerm()
+	perm.SetPath(aPaths, []string{"/x"})
+	perm.AddGroupPath("editor", "/x")
+	perm.state.(*mockState).admins["alice"] = true
+
+	req := httptest.NewRequest(http.MethodGet, "/x", nil)
+	req.Header.Set("X-Test-User", "alice")
+	if perm.Rejected(httptest.NewRecorder(), req) {
+		t.Fatal("an admin should be granted access even when the same prefix is also group-gated")
+	}
+}
@@ -0,0 +1,33 @@
+package bperm
+
+import (
+	"fmt"
+	"net/smtp"
+)
+
+// Mailer sends account confirmation emails. Implement this to plug in a
+// different mail provider than the SMTP default.
+type Mailer interface {
+	// SendConfirmation sends a confirmation code to the given email address.
+	SendConfirmation(email, code string) error
+}
+
+// SMTPMailer is the default Mailer, sending confirmation emails through an
+// SMTP server.
+type SMTPMailer struct {
+	Addr string // "host:port" of the SMTP server
+	From string
+	Auth smtp.Auth
+}
+
+// NewSMTPMailer creates a new SMTPMailer that sends mail through the SMTP
+// server at addr, using the given From address and authentication.
+func NewSMTPMailer(addr, from string, auth smtp.Auth) *SMTPMailer {
+	return &SMTPMailer{Addr: addr, From: from, Auth: auth}
+}
+
+// SendConfirmation sends a confirmation code to the given email address.
+func (m *SMTPMailer) SendConfirmation(email, code string) error {
+	msg := []byte(fmt.Sprintf("Subject: Please confirm your account\r\n\r\nYour confirmation code is: %s\r\n", code))
+	return smtp.SendMail(m.Addr, m.Auth, m.From, []string{email}, msg)
+}
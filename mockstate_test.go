@@ -0,0 +1,54 @@
+package bperm
+
+import "net/http"
+
+// mockState is a minimal in-memory UserState used by the tests in this
+// package. The current user is read from the X-Test-User header rather
+// than a real session cookie, since session handling is the concern of
+// the backends under bperm/backends, not of Permissions itself.
+type mockState struct {
+	admins    map[string]bool
+	confirmed map[string]bool
+	groups    map[string][]string
+}
+
+func (m *mockState) Username(req *http.Request) string {
+	return req.Header.Get("X-Test-User")
+}
+
+func (m *mockState) Users() []string { return nil }
+
+func (m *mockState) HasUser(username string) bool { return false }
+
+func (m *mockState) Login(w http.ResponseWriter, username string) {}
+
+func (m *mockState) Logout(username string) {}
+
+func (m *mockState) IsLoggedIn(username string) bool { return username != "" }
+
+func (m *mockState) IsConfirmed(username string) bool { return m.confirmed[username] }
+
+func (m *mockState) AddUser(username, password, email string) error { return nil }
+
+func (m *mockState) RemoveUser(username string) {}
+
+func (m *mockState) SetAdminStatus(username string) { m.admins[username] = true }
+
+func (m *mockState) RemoveAdminStatus(username string) { delete(m.admins, username) }
+
+func (m *mockState) IsCurrentUserAdmin(req *http.Request) (bool, error) {
+	return m.admins[m.Username(req)], nil
+}
+
+func (m *mockState) AddGroup(name string) error { return nil }
+
+func (m *mockState) AssignUserToGroup(username, group string) error {
+	m.groups[username] = append(m.groups[username], group)
+	return nil
+}
+
+func (m *mockState) UserGroups(username string) []string { return m.groups[username] }
+
+func (m *mockState) GenerateConfirmationCode(username string) (string, error) { return "", nil }
+
+func (m *mockState) ConfirmUser(code string) error { return nil }
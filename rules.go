@@ -0,0 +1,126 @@
+package bperm
+
+import (
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// Rule describes a single, method-aware path match, compiled once and
+// checked before falling back to the legacy prefix-based paths. Pattern
+// supports exact matches, "*" wildcard segments (or a trailing "/*" to
+// match everything under a path), and ":param" segments that match any
+// single path segment. When several rules match the same request, the
+// most specific one wins; on a tie, admin overrides user overrides
+// public.
+type Rule struct {
+	Methods []string // HTTP methods this rule applies to; empty means all methods
+	Pattern string
+	Kind    Paths
+}
+
+// compiledRule is a Rule with its pattern pre-compiled into a regular
+// expression, along with a specificity score used to break ties between
+// matching rules.
+type compiledRule struct {
+	rule        Rule
+	re          *regexp.Regexp
+	specificity int
+}
+
+// compileRule compiles a Rule's pattern into a regular expression.
+func compileRule(rule Rule) compiledRule {
+	pattern := rule.Pattern
+
+	if strings.HasSuffix(pattern, "/*") {
+		prefix := strings.TrimSuffix(pattern, "/*")
+		re := regexp.MustCompile("^" + regexp.QuoteMeta(prefix) + "(/.*)?$")
+		return compiledRule{rule: rule, re: re, specificity: strings.Count(prefix, "/")}
+	}
+
+	segments := strings.Split(pattern, "/")
+	parts := make([]string, len(segments))
+	specificity := len(segments)
+	for i, seg := range segments {
+		switch {
+		case seg == "*":
+			parts[i] = "[^/]*"
+			specificity--
+		case strings.HasPrefix(seg, ":") && len(seg) > 1:
+			parts[i] = "[^/]+"
+			specificity--
+		default:
+			parts[i] = regexp.QuoteMeta(seg)
+		}
+	}
+	re := regexp.MustCompile("^" + strings.Join(parts, "/") + "$")
+	return compiledRule{rule: rule, re: re, specificity: specificity}
+}
+
+// AddRule adds a single Rule to the compiled rule set.
+func (perm *Permissions) AddRule(rule Rule) {
+	perm.rules = append(perm.rules, compileRule(rule))
+}
+
+// SetRules replaces the compiled rule set with the given rules.
+func (perm *Permissions) SetRules(rules []Rule) {
+	compiled := make([]compiledRule, len(rules))
+	for i, rule := range rules {
+		compiled[i] = compileRule(rule)
+	}
+	perm.rules = compiled
+}
+
+// matchRule finds the most specific rule matching the given request, if
+// any. On a tie in specificity, admin overrides user overrides public.
+func (perm *Permissions) matchRule(req *http.Request) (Paths, bool) {
+	var best *compiledRule
+	for i := range perm.rules {
+		candidate := &perm.rules[i]
+		if len(candidate.rule.Methods) > 0 && !methodMatches(candidate.rule.Methods, req.Method) {
+			continue
+		}
+		if !candidate.re.MatchString(req.URL.Path) {
+			continue
+		}
+		if best == nil || moreSpecific(candidate, best) {
+			best = candidate
+		}
+	}
+	if best == nil {
+		return "", false
+	}
+	return best.rule.Kind, true
+}
+
+// moreSpecific reports whether a should win over b when both match.
+func moreSpecific(a, b *compiledRule) bool {
+	if a.specificity != b.specificity {
+		return a.specificity > b.specificity
+	}
+	return kindPriority(a.rule.Kind) > kindPriority(b.rule.Kind)
+}
+
+// kindPriority ranks the path buckets for tie-breaking: admin overrides
+// user overrides public.
+func kindPriority(kind Paths) int {
+	switch kind {
+	case aPaths:
+		return 3
+	case uPaths:
+		return 2
+	case pPaths:
+		return 1
+	}
+	return 0
+}
+
+// methodMatches checks if method is present in methods, case-insensitively.
+func methodMatches(methods []string, method string) bool {
+	for _, m := range methods {
+		if strings.EqualFold(m, method) {
+			return true
+		}
+	}
+	return false
+}
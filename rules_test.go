@@ -0,0 +1,102 @@
+package bperm
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func newPerm() *Permissions {
+	return NewFromUserState(&mockState{
+		admins:    map[string]bool{},
+		confirmed: map[string]bool{},
+		groups:    map[string][]string{},
+	})
+}
+
+func TestMatchRule_ExactPatternDoesNotMatchLongerPath(t *testing.T) {
+	perm := newPerm()
+	perm.AddRule(Rule{Pattern: "/admin", Kind: aPaths})
+
+	req := httptest.NewRequest(http.MethodGet, "/administrators", nil)
+	if _, ok := perm.matchRule(req); ok {
+		t.Fatal("/admin should not match /administrators")
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/admin", nil)
+	kind, ok := perm.matchRule(req)
+	if !ok || kind != aPaths {
+		t.Fatalf("/admin should match the /admin rule, got kind=%v ok=%v", kind, ok)
+	}
+}
+
+func TestMatchRule_WildcardSuffixMatchesNested(t *testing.T) {
+	perm := newPerm()
+	perm.AddRule(Rule{Pattern: "/files/*", Kind: uPaths})
+
+	for _, path := range []string{"/files", "/files/a", "/files/a/b"} {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if _, ok := perm.matchRule(req); !ok {
+			t.Errorf("expected /files/* to match %q", path)
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/filesystem", nil)
+	if _, ok := perm.matchRule(req); ok {
+		t.Fatal("/files/* should not match /filesystem")
+	}
+}
+
+func TestMatchRule_ParamSegmentMatchesOneSegment(t *testing.T) {
+	perm := newPerm()
+	perm.AddRule(Rule{Pattern: "/users/:id", Kind: uPaths})
+
+	req := httptest.NewRequest(http.MethodGet, "/users/42", nil)
+	if _, ok := perm.matchRule(req); !ok {
+		t.Fatal("/users/:id should match /users/42")
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/users/42/edit", nil)
+	if _, ok := perm.matchRule(req); ok {
+		t.Fatal("/users/:id should not match /users/42/edit")
+	}
+}
+
+func TestMatchRule_MethodScopesTheRule(t *testing.T) {
+	perm := newPerm()
+	perm.AddRule(Rule{Methods: []string{"POST"}, Pattern: "/comments", Kind: uPaths})
+
+	req := httptest.NewRequest(http.MethodGet, "/comments", nil)
+	if _, ok := perm.matchRule(req); ok {
+		t.Fatal("a POST-only rule should not match a GET request")
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/comments", nil)
+	if _, ok := perm.matchRule(req); !ok {
+		t.Fatal("a POST-only rule should match a POST request")
+	}
+}
+
+func TestMatchRule_TieBreaksByKindPriority(t *testing.T) {
+	perm := newPerm()
+	perm.AddRule(Rule{Pattern: "/shared", Kind: pPaths})
+	perm.AddRule(Rule{Pattern: "/shared", Kind: aPaths})
+
+	req := httptest.NewRequest(http.MethodGet, "/shared", nil)
+	kind, ok := perm.matchRule(req)
+	if !ok || kind != aPaths {
+		t.Fatalf("equally specific rules should tie-break to admin, got kind=%v ok=%v", kind, ok)
+	}
+}
+
+func TestMatchRule_MoreSpecificPatternWins(t *testing.T) {
+	perm := newPerm()
+	perm.AddRule(Rule{Pattern: "/files/*", Kind: pPaths})
+	perm.AddRule(Rule{Pattern: "/files/secret", Kind: aPaths})
+
+	req := httptest.NewRequest(http.MethodGet, "/files/secret", nil)
+	kind, ok := perm.matchRule(req)
+	if !ok || kind != aPaths {
+		t.Fatalf("the more specific rule should win, got kind=%v ok=%v", kind, ok)
+	}
+}
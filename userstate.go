@@ -0,0 +1,68 @@
+package bperm
+
+import "net/http"
+
+// UserState is the interface that any user storage backend must implement
+// in order to be used by Permissions. It covers authentication state,
+// user/admin bookkeeping and login/logout handling.
+//
+// The default implementation is backed by Redis and lives in
+// bperm/backends/redis. Additional implementations are provided under
+// bperm/backends/bolt, bperm/backends/mysql and bperm/backends/postgres,
+// so that a storage engine can be picked without forking this module.
+type UserState interface {
+	// Username returns the username of the current user, if any.
+	Username(req *http.Request) string
+
+	// Users returns every known username.
+	Users() []string
+
+	// HasUser checks if the given username exists.
+	HasUser(username string) bool
+
+	// Login marks the given username as logged in.
+	Login(w http.ResponseWriter, username string)
+
+	// Logout marks the given username as logged out.
+	Logout(username string)
+
+	// IsLoggedIn checks if the given username is logged in.
+	IsLoggedIn(username string) bool
+
+	// IsConfirmed checks if the given username has confirmed their account.
+	IsConfirmed(username string) bool
+
+	// AddUser adds a new user with the given username, password and email.
+	AddUser(username, password, email string) error
+
+	// RemoveUser removes the given username.
+	RemoveUser(username string)
+
+	// SetAdminStatus marks the given username as an administrator.
+	SetAdminStatus(username string)
+
+	// RemoveAdminStatus removes administrator rights from the given username.
+	RemoveAdminStatus(username string)
+
+	// IsCurrentUserAdmin checks if the current user, as identified by the
+	// request, is an administrator.
+	IsCurrentUserAdmin(req *http.Request) (bool, error)
+
+	// AddGroup creates a new named group, if it does not already exist.
+	AddGroup(name string) error
+
+	// AssignUserToGroup adds the given username to the named group. The
+	// group is created first if it does not already exist.
+	AssignUserToGroup(username, group string) error
+
+	// UserGroups returns every group the given username belongs to.
+	UserGroups(username string) []string
+
+	// GenerateConfirmationCode generates and stores a new confirmation
+	// code for the given username, to be handed to ConfirmUser later.
+	GenerateConfirmationCode(username string) (string, error)
+
+	// ConfirmUser marks the user owning the given confirmation code as
+	// confirmed. It returns an error if the code is unknown.
+	ConfirmUser(code string) error
+}